@@ -0,0 +1,41 @@
+package main
+
+//leafPrefix and nodePrefix tag leaf and internal-node preimages with different
+//leading bytes, so a leaf's preimage can never be replayed as an internal
+//node's, and vice versa.
+var (
+	leafPrefix = []byte{0x00}
+	nodePrefix = []byte{0x01}
+)
+
+//hashLeaf computes the hash a leaf's Content contributes to the tree. With
+//domainSeparated it is H(0x00 || contentHash); with LegacyMode it is just the
+//Content's own hash, unchanged from the tree's original behavior.
+func hashLeaf(contentHash []byte, hashStrategy HashStrategy, domainSeparated bool) ([]byte, error) {
+	if !domainSeparated {
+		return contentHash, nil
+	}
+
+	h := hashStrategy()
+	if _, err := h.Write(append(append([]byte{}, leafPrefix...), contentHash...)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+//hashChildren computes the hash of an internal node from its two children's
+//hashes. With domainSeparated it is H(0x01 || left || right); with LegacyMode
+//it is the tree's original H(left || right).
+func hashChildren(left, right []byte, hashStrategy HashStrategy, domainSeparated bool) ([]byte, error) {
+	h := hashStrategy()
+	preimage := append([]byte{}, left...)
+	if domainSeparated {
+		preimage = append(append([]byte{}, nodePrefix...), preimage...)
+	}
+	preimage = append(preimage, right...)
+
+	if _, err := h.Write(preimage); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+//maxFileBlockSize caps the block size DetermineBlockSize will pick.
+const maxFileBlockSize = 16 * 1024
+
+//minFileBlockSize is the smallest block size DetermineBlockSize will pick.
+const minFileBlockSize = 512
+
+//DetermineBlockSize picks a power-of-two block size for a file of totalSize
+//bytes, aiming for roughly 256 blocks and never exceeding maxFileBlockSize.
+func DetermineBlockSize(totalSize int) int {
+	if totalSize <= minFileBlockSize {
+		return minFileBlockSize
+	}
+
+	blockSize := minFileBlockSize
+	for blockSize < maxFileBlockSize && totalSize/blockSize > 256 {
+		blockSize *= 2
+	}
+	return blockSize
+}
+
+//blockContent is the Content wrapping a single fixed-size block of a FileContent.
+type blockContent struct {
+	data []byte
+}
+
+//CalculateHash hashes the raw bytes of the block.
+func (b blockContent) CalculateHash() ([]byte, error) {
+	h := sha256.New()
+	if _, err := h.Write(b.data); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+//Equals tests for equality of two blockContent values.
+func (b blockContent) Equals(other Content) (bool, error) {
+	o, ok := other.(blockContent)
+	if !ok {
+		return false, errors.New("error: cannot compare blockContent to a different Content type")
+	}
+	return bytes.Equal(b.data, o.data), nil
+}
+
+//FileContent implements Content for a large file by chunking it into fixed-size
+//blocks, hashing each block, and building a sub-Merkle-tree over those blocks
+//whose root becomes FileContent's own hash. Every block's bytes are kept in the
+//leaves of that sub-tree, so the whole file is held in memory for as long as
+//the FileContent exists.
+type FileContent struct {
+	blockTree *MerkleTree
+	size      int64
+}
+
+//NewFileContent reads r to completion, splitting it into blockSize-sized blocks
+//(the last one may be shorter), and builds the block sub-tree. Use
+//DetermineBlockSize to pick blockSize from the file's total size.
+func NewFileContent(r io.Reader, blockSize int) (*FileContent, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("error: blockSize must be positive")
+	}
+
+	var blocks []Content
+	var size int64
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			blocks = append(blocks, blockContent{data: data})
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New("error: cannot build FileContent from an empty reader")
+	}
+
+	tree, err := Setup(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return &FileContent{blockTree: tree, size: size}, nil
+}
+
+//CalculateHash returns the root hash of the block sub-tree.
+func (f *FileContent) CalculateHash() ([]byte, error) {
+	return f.blockTree.merkleRoot, nil
+}
+
+//Equals tests for equality of two FileContent values by comparing their block
+//sub-tree roots.
+func (f *FileContent) Equals(other Content) (bool, error) {
+	o, ok := other.(*FileContent)
+	if !ok {
+		return false, errors.New("error: cannot compare FileContent to a different Content type")
+	}
+	return bytes.Equal(f.blockTree.merkleRoot, o.blockTree.merkleRoot), nil
+}
+
+//ProofForBlock generates a Proof that the block at index belongs to this
+//FileContent's block sub-tree, verifiable with VerifyBlock.
+func (f *FileContent) ProofForBlock(index int) (*Proof, error) {
+	if index < 0 || index >= len(f.blockTree.Leafs) {
+		return nil, errors.New("error: block index out of range")
+	}
+	return f.blockTree.GenerateProof(f.blockTree.Leafs[index].C)
+}
+
+//VerifyBlock verifies that block is a block of a FileContent whose hash is
+//fileRoot, without needing the rest of the file in memory. blockIndex is only
+//checked to be non-negative; the block's position is not checked against
+//proof, and ProofForBlock returns the proof of the first block whose bytes match.
+func VerifyBlock(fileRoot []byte, blockIndex int, block []byte, proof *Proof) (bool, error) {
+	if blockIndex < 0 {
+		return false, errors.New("error: blockIndex must not be negative")
+	}
+
+	leafHash, err := (blockContent{data: block}).CalculateHash()
+	if err != nil {
+		return false, err
+	}
+	// NewFileContent always builds its block sub-tree via Setup, which is
+	// fixed to sha256.New with domain separation off; match that here.
+	return VerifyProof(fileRoot, leafHash, proof, sha256.New, false)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetermineBlockSize(t *testing.T) {
+	cases := []struct {
+		totalSize int
+		want      int
+	}{
+		{0, minFileBlockSize},
+		{minFileBlockSize, minFileBlockSize},
+		{minFileBlockSize*257 + 1, minFileBlockSize * 2},
+		{100 * 1024 * 1024, maxFileBlockSize},
+	}
+	for _, c := range cases {
+		got := DetermineBlockSize(c.totalSize)
+		if got != c.want {
+			t.Errorf("DetermineBlockSize(%d) = %d, want %d", c.totalSize, got, c.want)
+		}
+		if got > maxFileBlockSize {
+			t.Errorf("DetermineBlockSize(%d) = %d exceeds maxFileBlockSize", c.totalSize, got)
+		}
+	}
+}
+
+//TestNewFileContentChunksIntoBlocks checks that a file is split into the
+//expected number of blocks, with only the last one short.
+func TestNewFileContentChunksIntoBlocks(t *testing.T) {
+	data := strings.Repeat("x", 35)
+	fc, err := NewFileContent(strings.NewReader(data), 10)
+	if err != nil {
+		t.Fatalf("NewFileContent failed: %v", err)
+	}
+	// 35 bytes at blockSize 10 is 4 blocks (10, 10, 10, 5): an even count, so
+	// buildWithContent's odd-leaf duplication never kicks in.
+	if len(fc.blockTree.Leafs) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(fc.blockTree.Leafs))
+	}
+	if fc.size != int64(len(data)) {
+		t.Fatalf("size = %d, want %d", fc.size, len(data))
+	}
+	last := fc.blockTree.Leafs[3].C.(blockContent)
+	if len(last.data) != 5 {
+		t.Fatalf("last block is %d bytes, want 5", len(last.data))
+	}
+}
+
+//TestNewFileContentRejectsEmptyReader checks that an empty file is rejected
+//rather than producing a content-less leaf.
+func TestNewFileContentRejectsEmptyReader(t *testing.T) {
+	if _, err := NewFileContent(strings.NewReader(""), 10); err == nil {
+		t.Fatal("NewFileContent(empty reader) = nil error, want error")
+	}
+}
+
+//TestProofForBlockAndVerifyBlock exercises generating and verifying a proof
+//for a single block of a FileContent.
+func TestProofForBlockAndVerifyBlock(t *testing.T) {
+	data := strings.Repeat("y", 100)
+	fc, err := NewFileContent(strings.NewReader(data), 10)
+	if err != nil {
+		t.Fatalf("NewFileContent failed: %v", err)
+	}
+	fileRoot, err := fc.CalculateHash()
+	if err != nil {
+		t.Fatalf("CalculateHash failed: %v", err)
+	}
+
+	for i, leaf := range fc.blockTree.Leafs {
+		proof, err := fc.ProofForBlock(i)
+		if err != nil {
+			t.Fatalf("ProofForBlock(%d) failed: %v", i, err)
+		}
+		block := leaf.C.(blockContent).data
+		ok, err := VerifyBlock(fileRoot, i, block, proof)
+		if err != nil {
+			t.Fatalf("VerifyBlock(%d) failed: %v", i, err)
+		}
+		if !ok {
+			t.Fatalf("VerifyBlock(%d) = false, want true", i)
+		}
+	}
+
+	proof, err := fc.ProofForBlock(0)
+	if err != nil {
+		t.Fatalf("ProofForBlock(0) failed: %v", err)
+	}
+	ok, err := VerifyBlock(fileRoot, 0, []byte("tampered data!!!"), proof)
+	if err != nil {
+		t.Fatalf("VerifyBlock failed: %v", err)
+	}
+	if ok {
+		t.Fatal("VerifyBlock(tampered block) = true, want false")
+	}
+}
+
+func TestFileContentEquals(t *testing.T) {
+	a, err := NewFileContent(strings.NewReader("same content"), 4)
+	if err != nil {
+		t.Fatalf("NewFileContent failed: %v", err)
+	}
+	b, err := NewFileContent(strings.NewReader("same content"), 4)
+	if err != nil {
+		t.Fatalf("NewFileContent failed: %v", err)
+	}
+	c, err := NewFileContent(strings.NewReader("different content"), 4)
+	if err != nil {
+		t.Fatalf("NewFileContent failed: %v", err)
+	}
+
+	if ok, err := a.Equals(b); err != nil || !ok {
+		t.Fatalf("a.Equals(b) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := a.Equals(c); err != nil || ok {
+		t.Fatalf("a.Equals(c) = %v, %v, want false, nil", ok, err)
+	}
+	if _, err := a.Equals(TestContent{x: "not a FileContent"}); err == nil {
+		t.Fatal("a.Equals(wrong type) = nil error, want error")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "hash"
+
+//HashStrategy is the hash.Hash constructor a MerkleTree uses for every hash it
+//computes, from leaf content up to the root. sha256.New and sha512.New both
+//satisfy it, as does any caller-supplied constructor (e.g. for Blake2 or Keccak).
+type HashStrategy func() hash.Hash
+
+//Option configures a MerkleTree at construction time. See SetupWithOptions.
+type Option func(*MerkleTree)
+
+//WithHashStrategy overrides the default SHA-256 hash used to build and verify a
+//tree. All of VerifyTree, VerifyContent, and GenerateProof rely on the tree
+//having been built with the same strategy that is later used to verify it.
+func WithHashStrategy(hashStrategy HashStrategy) Option {
+	return func(t *MerkleTree) {
+		t.hashStrategy = hashStrategy
+	}
+}
+
+//WithStorage persists every node of the tree to storage, keyed by hash, as it
+//is built. SetupWithStorage does the same for a tree built like Setup (SHA-256,
+//no domain separation).
+func WithStorage(storage Storage) Option {
+	return func(t *MerkleTree) {
+		t.storage = storage
+	}
+}
+
+//LegacyMode disables domain separation, reverting to the tree's original
+//H(L||R) construction (with no 0x00/0x01 level prefix) so that roots computed
+//before domain separation was introduced stay reproducible.
+func LegacyMode() Option {
+	return func(t *MerkleTree) {
+		t.legacyMode = true
+	}
+}
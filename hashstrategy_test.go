@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha512"
+	"hash"
+	"hash/fnv"
+	"testing"
+)
+
+//TestWithHashStrategySHA512 checks that a tree built with WithHashStrategy
+//actually hashes with that strategy: the root must match a tree built the
+//same way by hand, and must differ from the SHA-256 default.
+func TestWithHashStrategySHA512(t *testing.T) {
+	cs := []Content{TestContent{x: "a"}, TestContent{x: "b"}, TestContent{x: "c"}}
+
+	sha512Tree, err := SetupWithOptions(cs, WithHashStrategy(sha512.New))
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+	if ok, err := sha512Tree.VerifyTree(); err != nil || !ok {
+		t.Fatalf("VerifyTree = %v, %v", ok, err)
+	}
+	if len(sha512Tree.Root.Hash) != sha512.Size {
+		t.Fatalf("root hash is %d bytes, want %d", len(sha512Tree.Root.Hash), sha512.Size)
+	}
+
+	root, _, err := buildWithContent(cs, sha512.New, !sha512Tree.legacyMode)
+	if err != nil {
+		t.Fatalf("buildWithContent failed: %v", err)
+	}
+	if string(root.Hash) != string(sha512Tree.Root.Hash) {
+		t.Fatal("SetupWithOptions root does not match buildWithContent root computed directly with sha512.New")
+	}
+
+	sha256Tree, err := SetupWithOptions(cs)
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+	if string(sha256Tree.Root.Hash) == string(sha512Tree.Root.Hash) {
+		t.Fatal("sha256 and sha512 trees produced the same root")
+	}
+}
+
+//TestWithHashStrategyCustom checks that a caller-supplied HashStrategy (not
+//just sha256/sha512) is honored end to end.
+func TestWithHashStrategyCustom(t *testing.T) {
+	cs := []Content{TestContent{x: "x"}, TestContent{x: "y"}}
+	tree, err := SetupWithOptions(cs, WithHashStrategy(func() hash.Hash { return fnv.New32a() }))
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+	if ok, err := tree.VerifyTree(); err != nil || !ok {
+		t.Fatalf("VerifyTree = %v, %v", ok, err)
+	}
+	if len(tree.Root.Hash) != 4 {
+		t.Fatalf("root hash is %d bytes, want 4 (fnv32a output size)", len(tree.Root.Hash))
+	}
+}
@@ -41,9 +41,12 @@ type Content interface {
 //MerkleTree is the container for the tree. It holds a pointer to the root of the tree,
 //a list of pointers to the leaf nodes, and the merkle root.
 type MerkleTree struct {
-	Root       *Node
-	merkleRoot []byte
-	Leafs      []*Node
+	Root         *Node
+	merkleRoot   []byte
+	Leafs        []*Node
+	storage      Storage
+	hashStrategy HashStrategy
+	legacyMode   bool
 }
 
 //Node represents a node, root, or leaf in the tree. It stores pointers to its immediate
@@ -60,53 +63,111 @@ type Node struct {
 
 //verifyNode walks down the tree until hitting a leaf, calculating the hash at each level
 //and returning the resulting hash of Node n.
-func (n *Node) verifyNode() ([]byte, error) {
+func (n *Node) verifyNode(hashStrategy HashStrategy, domainSeparated bool) ([]byte, error) {
 	if n.leaf {
-		return n.C.CalculateHash()
+		if n.C == nil {
+			// A leaf rehydrated by LoadTree has no Content to recompute from;
+			// trust the hash storage already vouched for.
+			return n.Hash, nil
+		}
+		contentHash, err := n.C.CalculateHash()
+		if err != nil {
+			return nil, err
+		}
+		return hashLeaf(contentHash, hashStrategy, domainSeparated)
 	}
-	rightBytes, err := n.Right.verifyNode()
+	rightBytes, err := n.Right.verifyNode(hashStrategy, domainSeparated)
 	if err != nil {
 		return nil, err
 	}
 
-	leftBytes, err := n.Left.verifyNode()
+	leftBytes, err := n.Left.verifyNode(hashStrategy, domainSeparated)
 	if err != nil {
 		return nil, err
 	}
 
-	h := sha256.New()
-	if _, err := h.Write(append(leftBytes, rightBytes...)); err != nil {
-		return nil, err
-	}
-
-	return h.Sum(nil), nil
+	return hashChildren(leftBytes, rightBytes, hashStrategy, domainSeparated)
 }
 
 //calculateNodeHash is a helper function that calculates the hash of the node.
-func (n *Node) calculateNodeHash() ([]byte, error) {
+func (n *Node) calculateNodeHash(hashStrategy HashStrategy, domainSeparated bool) ([]byte, error) {
 	if n.leaf {
-		return n.C.CalculateHash()
-	}
-
-	h := sha256.New()
-	if _, err := h.Write(append(n.Left.Hash, n.Right.Hash...)); err != nil {
-		return nil, err
+		contentHash, err := n.C.CalculateHash()
+		if err != nil {
+			return nil, err
+		}
+		return hashLeaf(contentHash, hashStrategy, domainSeparated)
 	}
 
-	return h.Sum(nil), nil
+	return hashChildren(n.Left.Hash, n.Right.Hash, hashStrategy, domainSeparated)
 }
 
 // Creates a new Merkle Tree using the content cs.
 func Setup(cs []Content) (*MerkleTree, error) {
 	defer timeTrack(time.Now(), "Setup")
-	root, leafs, err := buildWithContent(cs)
+	root, leafs, err := buildWithContent(cs, sha256.New, false)
+	if err != nil {
+		return nil, err
+	}
+	t := &MerkleTree{
+		Root:         root,
+		merkleRoot:   root.Hash,
+		Leafs:        leafs,
+		hashStrategy: sha256.New,
+		legacyMode:   true,
+	}
+	return t, nil
+}
+
+//SetupWithStorage creates a new Merkle Tree using the content cs, persisting every
+//node to storage keyed by its hash as it is built. Passing a nil storage behaves
+//exactly like Setup.
+func SetupWithStorage(cs []Content, storage Storage) (*MerkleTree, error) {
+	defer timeTrack(time.Now(), "SetupWithStorage")
+	root, leafs, err := buildWithContent(cs, sha256.New, false)
 	if err != nil {
 		return nil, err
 	}
 	t := &MerkleTree{
-		Root:       root,
-		merkleRoot: root.Hash,
-		Leafs:      leafs,
+		Root:         root,
+		merkleRoot:   root.Hash,
+		Leafs:        leafs,
+		storage:      storage,
+		hashStrategy: sha256.New,
+		legacyMode:   true,
+	}
+	if storage != nil {
+		if err := t.persistTree(root); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+//SetupWithOptions creates a new Merkle Tree using the content cs, configured by opts.
+//Without any options it hashes with domain-separated, RFC 6962 style preimages;
+//WithHashStrategy lets callers pick SHA-256, SHA-512, or any other hash.Hash
+//constructor, and LegacyMode reverts to the original non-domain-separated H(L||R)
+//construction used by Setup, for roots that must stay reproducible.
+func SetupWithOptions(cs []Content, opts ...Option) (*MerkleTree, error) {
+	defer timeTrack(time.Now(), "SetupWithOptions")
+	t := &MerkleTree{hashStrategy: sha256.New}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	root, leafs, err := buildWithContent(cs, t.hashStrategy, !t.legacyMode)
+	if err != nil {
+		return nil, err
+	}
+	t.Root = root
+	t.merkleRoot = root.Hash
+	t.Leafs = leafs
+
+	if t.storage != nil {
+		if err := t.persistTree(root); err != nil {
+			return nil, err
+		}
 	}
 	return t, nil
 }
@@ -114,13 +175,17 @@ func Setup(cs []Content) (*MerkleTree, error) {
 //buildWithContent is a helper function that for a given set of Contents, generates a
 //corresponding tree and returns the root node, a list of leaf nodes, and a possible error.
 //Returns an error if cs contains no Contents.
-func buildWithContent(cs []Content) (*Node, []*Node, error) {
+func buildWithContent(cs []Content, hashStrategy HashStrategy, domainSeparated bool) (*Node, []*Node, error) {
 	if len(cs) == 0 {
 		return nil, nil, errors.New("error: cannot construct tree with no content")
 	}
 	var leafs []*Node
 	for _, c := range cs {
-		hash, err := c.CalculateHash()
+		contentHash, err := c.CalculateHash()
+		if err != nil {
+			return nil, nil, err
+		}
+		hash, err := hashLeaf(contentHash, hashStrategy, domainSeparated)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -140,7 +205,7 @@ func buildWithContent(cs []Content) (*Node, []*Node, error) {
 		}
 		leafs = append(leafs, duplicate)
 	}
-	root, err := buildIntermediate(leafs)
+	root, err := buildIntermediate(leafs, hashStrategy, domainSeparated)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -150,37 +215,54 @@ func buildWithContent(cs []Content) (*Node, []*Node, error) {
 
 //buildIntermediate is a helper function that for a given list of leaf nodes, constructs
 //the intermediate and root levels of the tree. Returns the resulting root node of the tree.
-func buildIntermediate(nl []*Node) (*Node, error) {
-	var nodes []*Node
-	for i := 0; i < len(nl); i += 2 {
-		h := sha256.New()
-		var left, right int = i, i + 1
-		if i+1 == len(nl) {
-			right = i
+//Levels with at least ParallelThreshold nodes hash their sibling pairs across a worker
+//pool; smaller levels are hashed serially to avoid paying goroutine overhead for no
+//benefit. Either way results are written into an index-ordered slice before the next
+//level recurses, so the resulting tree is identical regardless of which path ran.
+func buildIntermediate(nl []*Node, hashStrategy HashStrategy, domainSeparated bool) (*Node, error) {
+	numPairs := (len(nl) + 1) / 2
+	nodes := make([]*Node, numPairs)
+
+	hashPair := func(idx int) error {
+		left, right := idx*2, idx*2+1
+		if right == len(nl) {
+			right = left
 		}
-		chash := append(nl[left].Hash, nl[right].Hash...)
-		if _, err := h.Write(chash); err != nil {
-			return nil, err
+		nodeHash, err := hashChildren(nl[left].Hash, nl[right].Hash, hashStrategy, domainSeparated)
+		if err != nil {
+			return err
 		}
 		n := &Node{
 			Left:  nl[left],
 			Right: nl[right],
-			Hash:  h.Sum(nil),
+			Hash:  nodeHash,
 		}
-		nodes = append(nodes, n)
 		nl[left].Parent = n
 		nl[right].Parent = n
-		if len(nl) == 2 {
-			return n, nil
+		nodes[idx] = n
+		return nil
+	}
+
+	if len(nl) < ParallelThreshold {
+		for idx := 0; idx < numPairs; idx++ {
+			if err := hashPair(idx); err != nil {
+				return nil, err
+			}
 		}
+	} else if err := hashPairsConcurrently(numPairs, hashPair); err != nil {
+		return nil, err
 	}
-	return buildIntermediate(nodes)
+
+	if len(nl) == 2 {
+		return nodes[0], nil
+	}
+	return buildIntermediate(nodes, hashStrategy, domainSeparated)
 }
 
 //VerifyTree verify tree validates the hashes at each level of the tree and returns true if the
 //resulting hash at the root of the tree matches the resulting root hash; returns false otherwise.
 func (m *MerkleTree) VerifyTree() (bool, error) {
-	calculatedMerkleRoot, err := m.Root.verifyNode()
+	calculatedMerkleRoot, err := m.Root.verifyNode(m.hashStrategy, !m.legacyMode)
 	if err != nil {
 		return false, err
 	}
@@ -196,6 +278,10 @@ func (m *MerkleTree) VerifyTree() (bool, error) {
 //for a given content. Returns true if valid and false otherwise.
 func (m *MerkleTree) VerifyContent(content Content) (bool, error) {
 	for _, l := range m.Leafs {
+		if l.C == nil {
+			// A leaf rehydrated by LoadTree has no Content to compare against.
+			continue
+		}
 		ok, err := l.C.Equals(content)
 		if err != nil {
 			return false, err
@@ -204,33 +290,24 @@ func (m *MerkleTree) VerifyContent(content Content) (bool, error) {
 		if ok {
 			currentParent := l.Parent
 			for currentParent != nil {
-				h := sha256.New()
-				rightBytes, err := currentParent.Right.calculateNodeHash()
+				rightBytes, err := currentParent.Right.calculateNodeHash(m.hashStrategy, !m.legacyMode)
+				if err != nil {
+					return false, err
+				}
+
+				leftBytes, err := currentParent.Left.calculateNodeHash(m.hashStrategy, !m.legacyMode)
 				if err != nil {
 					return false, err
 				}
 
-				leftBytes, err := currentParent.Left.calculateNodeHash()
+				computedHash, err := hashChildren(leftBytes, rightBytes, m.hashStrategy, !m.legacyMode)
 				if err != nil {
 					return false, err
 				}
-				if currentParent.Left.leaf && currentParent.Right.leaf {
-					if _, err := h.Write(append(leftBytes, rightBytes...)); err != nil {
-						return false, err
-					}
-					if bytes.Compare(h.Sum(nil), currentParent.Hash) != 0 {
-						return false, nil
-					}
-					currentParent = currentParent.Parent
-				} else {
-					if _, err := h.Write(append(leftBytes, rightBytes...)); err != nil {
-						return false, err
-					}
-					if bytes.Compare(h.Sum(nil), currentParent.Hash) != 0 {
-						return false, nil
-					}
-					currentParent = currentParent.Parent
+				if bytes.Compare(computedHash, currentParent.Hash) != 0 {
+					return false, nil
 				}
+				currentParent = currentParent.Parent
 			}
 			return true, nil
 		}
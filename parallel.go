@@ -0,0 +1,56 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+//ParallelThreshold is the minimum number of nodes a tree level must have before
+//buildIntermediate hashes sibling pairs across a worker pool instead of serially.
+//Below it, the overhead of spinning up goroutines outweighs the benefit.
+var ParallelThreshold = 1024
+
+//hashPairsConcurrently runs work(0), work(1), ..., work(n-1) across a pool of
+//runtime.NumCPU() workers and waits for all of them to finish. Each call writes
+//into a disjoint slot of the caller's result slice, so results land in index
+//order with no additional synchronization required.
+func hashPairsConcurrently(n int, work func(idx int) error) error {
+	workers := runtime.NumCPU()
+	if workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if err := work(idx); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	for idx := 0; idx < n; idx++ {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func buildLeafContents(n int) []Content {
+	cs := make([]Content, n)
+	for i := 0; i < n; i++ {
+		cs[i] = TestContent{x: fmt.Sprintf("leaf-%d", i)}
+	}
+	return cs
+}
+
+//TestBuildIntermediateSerialVsConcurrentMatch checks that forcing the serial
+//path (ParallelThreshold above the leaf count) and forcing the concurrent path
+//(ParallelThreshold of 1) build identical trees, across both even and odd leaf
+//counts and a few different tree depths.
+func TestBuildIntermediateSerialVsConcurrentMatch(t *testing.T) {
+	original := ParallelThreshold
+	defer func() { ParallelThreshold = original }()
+
+	for _, n := range []int{4, 5, 6, 7, 8, 31, 32} {
+		cs := buildLeafContents(n)
+
+		ParallelThreshold = 1 << 30
+		serialTree, err := Setup(cs)
+		if err != nil {
+			t.Fatalf("n=%d: serial Setup failed: %v", n, err)
+		}
+
+		ParallelThreshold = 1
+		concurrentTree, err := Setup(cs)
+		if err != nil {
+			t.Fatalf("n=%d: concurrent Setup failed: %v", n, err)
+		}
+
+		if !bytes.Equal(serialTree.Root.Hash, concurrentTree.Root.Hash) {
+			t.Fatalf("n=%d: serial root %x != concurrent root %x", n, serialTree.Root.Hash, concurrentTree.Root.Hash)
+		}
+		for i := range serialTree.Leafs {
+			if !bytes.Equal(serialTree.Leafs[i].Hash, concurrentTree.Leafs[i].Hash) {
+				t.Fatalf("n=%d: leaf %d hash mismatch between serial and concurrent builds", n, i)
+			}
+		}
+	}
+}
+
+//BenchmarkBuildIntermediate times Setup (and so buildIntermediate's concurrent
+//path) at sizes from 10k to 1M leaves. Run with: go test -bench BuildIntermediate -benchtime=1x
+func BenchmarkBuildIntermediate(b *testing.B) {
+	for _, n := range []int{10_000, 100_000, 1_000_000} {
+		cs := buildLeafContents(n)
+		b.Run(fmt.Sprintf("%d-leaves", n), func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Setup(cs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
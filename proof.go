@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+//Proof represents the sibling-hash path from a leaf up to the root of a MerkleTree.
+//Siblings holds the hash encountered at each level and Path records, for the same
+//index, whether that sibling sits to the right (true) or left (false) of the node
+//on the path being proven. A Proof is self-contained: VerifyProof only needs the
+//expected root, the leaf hash and the tree's hash settings to check it, the tree
+//itself is not required.
+type Proof struct {
+	Siblings [][]byte
+	Path     []bool
+}
+
+//maxProofDepth is the number of levels a single 2-byte flag bitmap can address,
+//capping the compact wire format at trees with up to 2^16 leafs.
+const maxProofDepth = 16
+
+//LeafHash computes the hash content would have as a leaf of this tree, i.e. the
+//leafHash VerifyProof expects: content.CalculateHash() passed through hashLeaf
+//with this tree's hashStrategy and domain separation setting. Callers should use
+//this rather than content.CalculateHash() directly, since on a domain-separated
+//tree (the SetupWithOptions default) those two values differ and VerifyProof
+//only accepts the former.
+func (m *MerkleTree) LeafHash(content Content) ([]byte, error) {
+	contentHash, err := content.CalculateHash()
+	if err != nil {
+		return nil, err
+	}
+	return hashLeaf(contentHash, m.hashStrategy, !m.legacyMode)
+}
+
+//GenerateProof builds a Proof that content is a member of the tree by walking from
+//the matching leaf to the root and recording the sibling hash at each level along
+//with its left/right position. Returns an error if content is not found in the tree.
+func (m *MerkleTree) GenerateProof(content Content) (*Proof, error) {
+	for _, l := range m.Leafs {
+		if l.C == nil {
+			// A leaf rehydrated by LoadTree has no Content to compare against.
+			continue
+		}
+		ok, err := l.C.Equals(content)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		var siblings [][]byte
+		var path []bool
+		current := l
+		for current.Parent != nil {
+			parent := current.Parent
+			if parent.Left == current {
+				siblings = append(siblings, parent.Right.Hash)
+				path = append(path, true)
+			} else {
+				siblings = append(siblings, parent.Left.Hash)
+				path = append(path, false)
+			}
+			current = parent
+		}
+		return &Proof{Siblings: siblings, Path: path}, nil
+	}
+	return nil, errors.New("error: content not found in tree")
+}
+
+//VerifyProof recomputes the root hash from leafHash and proof alone and reports
+//whether it matches root. It does not require the MerkleTree that produced the
+//proof, but it does need that tree's hashStrategy and domainSeparated setting
+//(see HashStrategy and LegacyMode) so it rebuilds hashes exactly the way that
+//tree did. leafHash must be the leaf's hash as the tree computed it, not
+//content.CalculateHash() directly: on a domain-separated tree those differ.
+//Use MerkleTree.LeafHash (or hashLeaf with the same settings) to derive it.
+func VerifyProof(root []byte, leafHash []byte, proof *Proof, hashStrategy HashStrategy, domainSeparated bool) (bool, error) {
+	if len(proof.Siblings) != len(proof.Path) {
+		return false, errors.New("error: malformed proof, siblings and path length mismatch")
+	}
+
+	current := leafHash
+	for i, sibling := range proof.Siblings {
+		var err error
+		if proof.Path[i] {
+			current, err = hashChildren(current, sibling, hashStrategy, domainSeparated)
+		} else {
+			current, err = hashChildren(sibling, current, hashStrategy, domainSeparated)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return bytes.Equal(current, root), nil
+}
+
+//VerifyProof is the tree-bound counterpart of the standalone VerifyProof
+//function: it verifies proof against this tree's own merkleRoot, using this
+//tree's hashStrategy and domain separation setting. leafHash must come from
+//m.LeafHash(content), not content.CalculateHash() directly.
+func (m *MerkleTree) VerifyProof(leafHash []byte, proof *Proof) (bool, error) {
+	return VerifyProof(m.merkleRoot, leafHash, proof, m.hashStrategy, !m.legacyMode)
+}
+
+//Bytes serializes the Proof into a compact wire format: a 2-byte bitmap header
+//(bit i set means the sibling at level i is on the right) followed by the
+//sibling hashes themselves, each hashStrategy().Size() bytes long. Returns an
+//error if the proof is deeper than maxProofDepth levels.
+func (p *Proof) Bytes(hashStrategy HashStrategy) ([]byte, error) {
+	if len(p.Siblings) != len(p.Path) {
+		return nil, errors.New("error: malformed proof, siblings and path length mismatch")
+	}
+	if len(p.Siblings) > maxProofDepth {
+		return nil, errors.New("error: proof depth exceeds compact encoding limit")
+	}
+
+	hashSize := hashStrategy().Size()
+	var flags uint16
+	for i, right := range p.Path {
+		if right {
+			flags |= 1 << uint(i)
+		}
+	}
+
+	buf := make([]byte, 2+len(p.Siblings)*hashSize)
+	binary.BigEndian.PutUint16(buf[:2], flags)
+	for i, sib := range p.Siblings {
+		copy(buf[2+i*hashSize:], sib)
+	}
+	return buf, nil
+}
+
+//ProofFromBytes parses the compact wire format produced by Proof.Bytes, using
+//hashStrategy to determine how many bytes each sibling hash occupies.
+func ProofFromBytes(data []byte, hashStrategy HashStrategy) (*Proof, error) {
+	if len(data) < 2 {
+		return nil, errors.New("error: proof data too short")
+	}
+
+	hashSize := hashStrategy().Size()
+	flags := binary.BigEndian.Uint16(data[:2])
+	rest := data[2:]
+	if len(rest)%hashSize != 0 {
+		return nil, errors.New("error: proof data is not a multiple of the hash size")
+	}
+
+	n := len(rest) / hashSize
+	siblings := make([][]byte, n)
+	path := make([]bool, n)
+	for i := 0; i < n; i++ {
+		siblings[i] = append([]byte{}, rest[i*hashSize:(i+1)*hashSize]...)
+		path[i] = flags&(1<<uint(i)) != 0
+	}
+
+	return &Proof{Siblings: siblings, Path: path}, nil
+}
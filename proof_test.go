@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func buildTestEntries(n int) []Content {
+	cs := make([]Content, n)
+	for i := range cs {
+		cs[i] = TestContent{x: string(rune('a' + i))}
+	}
+	return cs
+}
+
+//TestGenerateVerifyProofDomainSeparated exercises GenerateProof/VerifyProof
+//end-to-end against a domain-separated tree (the SetupWithOptions default),
+//using LeafHash to get the leaf hash VerifyProof actually expects.
+func TestGenerateVerifyProofDomainSeparated(t *testing.T) {
+	cs := buildTestEntries(5)
+	tree, err := SetupWithOptions(cs)
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+
+	for _, c := range cs {
+		proof, err := tree.GenerateProof(c)
+		if err != nil {
+			t.Fatalf("GenerateProof(%v) failed: %v", c, err)
+		}
+		leafHash, err := tree.LeafHash(c)
+		if err != nil {
+			t.Fatalf("LeafHash(%v) failed: %v", c, err)
+		}
+		ok, err := tree.VerifyProof(leafHash, proof)
+		if err != nil {
+			t.Fatalf("VerifyProof(%v) failed: %v", c, err)
+		}
+		if !ok {
+			t.Fatalf("VerifyProof(%v) = false, want true", c)
+		}
+	}
+}
+
+//TestVerifyProofRejectsPlainContentHash checks that passing
+//content.CalculateHash() instead of tree.LeafHash(content) is rejected rather
+//than silently misverifying, on a domain-separated tree where the two differ.
+func TestVerifyProofRejectsPlainContentHash(t *testing.T) {
+	cs := buildTestEntries(4)
+	tree, err := SetupWithOptions(cs)
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+
+	proof, err := tree.GenerateProof(cs[0])
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+	plainHash, err := cs[0].CalculateHash()
+	if err != nil {
+		t.Fatalf("CalculateHash failed: %v", err)
+	}
+	ok, err := tree.VerifyProof(plainHash, proof)
+	if err != nil {
+		t.Fatalf("VerifyProof failed: %v", err)
+	}
+	if ok {
+		t.Fatal("VerifyProof(plain content hash) = true, want false")
+	}
+}
+
+//TestVerifyProofLegacyMode exercises the same round trip with LegacyMode, the
+//tree's original non-domain-separated hashing.
+func TestVerifyProofLegacyMode(t *testing.T) {
+	cs := buildTestEntries(6)
+	tree, err := SetupWithOptions(cs, LegacyMode())
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+
+	proof, err := tree.GenerateProof(cs[3])
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+	leafHash, err := tree.LeafHash(cs[3])
+	if err != nil {
+		t.Fatalf("LeafHash failed: %v", err)
+	}
+	ok, err := tree.VerifyProof(leafHash, proof)
+	if err != nil {
+		t.Fatalf("VerifyProof failed: %v", err)
+	}
+	if !ok {
+		t.Fatal("VerifyProof = false, want true")
+	}
+}
+
+//TestProofBytesRoundTrip checks that Bytes/ProofFromBytes preserve a proof's
+//siblings and path exactly.
+func TestProofBytesRoundTrip(t *testing.T) {
+	cs := buildTestEntries(7)
+	tree, err := SetupWithOptions(cs)
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+
+	proof, err := tree.GenerateProof(cs[2])
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	data, err := proof.Bytes(sha256.New)
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	decoded, err := ProofFromBytes(data, sha256.New)
+	if err != nil {
+		t.Fatalf("ProofFromBytes failed: %v", err)
+	}
+
+	if len(decoded.Siblings) != len(proof.Siblings) {
+		t.Fatalf("decoded has %d siblings, want %d", len(decoded.Siblings), len(proof.Siblings))
+	}
+	for i := range proof.Siblings {
+		if string(decoded.Siblings[i]) != string(proof.Siblings[i]) {
+			t.Fatalf("sibling %d mismatch after round trip", i)
+		}
+		if decoded.Path[i] != proof.Path[i] {
+			t.Fatalf("path bit %d mismatch after round trip", i)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"sort"
+	"time"
+)
+
+//sortedMapEntry is the Content stored in a tree built by NewSortedMap: a
+//(keyHash, valueHash) pair.
+type sortedMapEntry struct {
+	keyHash   []byte
+	valueHash []byte
+}
+
+//CalculateHash hashes the keyHash/valueHash pair together into the leaf hash.
+func (e sortedMapEntry) CalculateHash() ([]byte, error) {
+	h := sha256.New()
+	if _, err := h.Write(append(append([]byte{}, e.keyHash...), e.valueHash...)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+//Equals tests for equality of two sortedMapEntry values.
+func (e sortedMapEntry) Equals(other Content) (bool, error) {
+	o, ok := other.(sortedMapEntry)
+	if !ok {
+		return false, errors.New("error: cannot compare sortedMapEntry to a different Content type")
+	}
+	return bytes.Equal(e.keyHash, o.keyHash) && bytes.Equal(e.valueHash, o.valueHash), nil
+}
+
+//NewSortedMap builds a MerkleTree over entries whose root is deterministic
+//regardless of map iteration order: every key is hashed, the (keyHash,
+//valueHash) pairs are sorted by keyHash, and the tree is built over that fixed
+//order. The tree hashes with domain-separated preimages; use Setup/
+//SetupWithOptions directly if a non-domain-separated root is needed instead.
+func NewSortedMap(entries map[string]Content) (*MerkleTree, error) {
+	defer timeTrack(time.Now(), "NewSortedMap")
+	if len(entries) == 0 {
+		return nil, errors.New("error: cannot construct tree with no content")
+	}
+
+	pairs := make([]sortedMapEntry, 0, len(entries))
+	for key, value := range entries {
+		keyHash := sha256.Sum256([]byte(key))
+		valueHash, err := value.CalculateHash()
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, sortedMapEntry{keyHash: keyHash[:], valueHash: valueHash})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i].keyHash, pairs[j].keyHash) < 0
+	})
+
+	cs := make([]Content, len(pairs))
+	for i, p := range pairs {
+		cs[i] = p
+	}
+
+	root, leafs, err := buildWithContent(cs, sha256.New, true)
+	if err != nil {
+		return nil, err
+	}
+	t := &MerkleTree{
+		Root:         root,
+		merkleRoot:   root.Hash,
+		Leafs:        leafs,
+		hashStrategy: sha256.New,
+	}
+	return t, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+//TestNewSortedMapOrderIndependent checks that NewSortedMap's root does not
+//depend on the entries map's iteration order, by building it from the same
+//key/value pairs rebuilt into several differently-ordered maps.
+func TestNewSortedMapOrderIndependent(t *testing.T) {
+	pairs := []struct{ key, value string }{
+		{"zebra", "1"}, {"apple", "2"}, {"mango", "3"}, {"kiwi", "4"},
+	}
+
+	var roots [][]byte
+	for i := 0; i < 3; i++ {
+		entries := make(map[string]Content, len(pairs))
+		for _, p := range pairs {
+			entries[p.key] = TestContent{x: p.value}
+		}
+		tree, err := NewSortedMap(entries)
+		if err != nil {
+			t.Fatalf("NewSortedMap failed: %v", err)
+		}
+		roots = append(roots, tree.Root.Hash)
+	}
+
+	for i := 1; i < len(roots); i++ {
+		if string(roots[i]) != string(roots[0]) {
+			t.Fatal("NewSortedMap produced different roots across rebuilds of the same entries")
+		}
+	}
+}
+
+//TestNewSortedMapDetectsChange checks that changing a single value changes
+//the root.
+func TestNewSortedMapDetectsChange(t *testing.T) {
+	base := map[string]Content{"a": TestContent{x: "1"}, "b": TestContent{x: "2"}}
+	tree, err := NewSortedMap(base)
+	if err != nil {
+		t.Fatalf("NewSortedMap failed: %v", err)
+	}
+
+	changed := map[string]Content{"a": TestContent{x: "1"}, "b": TestContent{x: "2-changed"}}
+	changedTree, err := NewSortedMap(changed)
+	if err != nil {
+		t.Fatalf("NewSortedMap failed: %v", err)
+	}
+
+	if string(tree.Root.Hash) == string(changedTree.Root.Hash) {
+		t.Fatal("changing a value did not change the root")
+	}
+}
+
+//TestNewSortedMapDomainSeparated checks that NewSortedMap's leaves are
+//domain-separated (hashLeaf with the leaf prefix), not the entry's raw
+//CalculateHash output.
+func TestNewSortedMapDomainSeparated(t *testing.T) {
+	entries := map[string]Content{"a": TestContent{x: "1"}}
+	tree, err := NewSortedMap(entries)
+	if err != nil {
+		t.Fatalf("NewSortedMap failed: %v", err)
+	}
+
+	leaf := tree.Leafs[0]
+	rawHash, err := leaf.C.CalculateHash()
+	if err != nil {
+		t.Fatalf("CalculateHash failed: %v", err)
+	}
+	if string(leaf.Hash) == string(rawHash) {
+		t.Fatal("leaf hash equals the raw entry hash; expected domain separation to change it")
+	}
+
+	wantHash, err := hashLeaf(rawHash, sha256.New, true)
+	if err != nil {
+		t.Fatalf("hashLeaf failed: %v", err)
+	}
+	if string(leaf.Hash) != string(wantHash) {
+		t.Fatal("leaf hash does not match hashLeaf(rawHash, sha256.New, domainSeparated=true)")
+	}
+}
+
+//TestNewSortedMapEmpty checks that an empty map is rejected, matching
+//buildWithContent's behavior for no content.
+func TestNewSortedMapEmpty(t *testing.T) {
+	if _, err := NewSortedMap(map[string]Content{}); err == nil {
+		t.Fatal("NewSortedMap(empty map) = nil error, want error")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+//Storage is a key/value backend that a MerkleTree can persist its nodes to,
+//keyed by node hash, so the tree can later be reloaded with LoadTree. A tree
+//still keeps its whole node graph in memory while it is in use.
+type Storage interface {
+	Get(key []byte) ([]byte, error)
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	Batch(ops []BatchOp) error
+}
+
+//BatchOp is a single operation applied atomically as part of a Storage.Batch call.
+//A nil Value means the op deletes Key rather than writing it.
+type BatchOp struct {
+	Key   []byte
+	Value []byte
+}
+
+//MemoryStorage is an in-memory Storage backed by a map, useful for tests and for
+//trees that fit comfortably in RAM but still want the Storage-backed code paths.
+type MemoryStorage struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+//NewMemoryStorage creates an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{data: make(map[string][]byte)}
+}
+
+//Get returns the value stored under key, or an error if it is not present.
+func (s *MemoryStorage) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[string(key)]
+	if !ok {
+		return nil, leveldb.ErrNotFound
+	}
+	return v, nil
+}
+
+//Put writes value under key, overwriting any existing entry.
+func (s *MemoryStorage) Put(key []byte, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[string(key)] = value
+	return nil
+}
+
+//Delete removes key. It is not an error to delete a key that is not present.
+func (s *MemoryStorage) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, string(key))
+	return nil
+}
+
+//Batch applies ops as a single critical section.
+func (s *MemoryStorage) Batch(ops []BatchOp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range ops {
+		if op.Value == nil {
+			delete(s.data, string(op.Key))
+			continue
+		}
+		s.data[string(op.Key)] = op.Value
+	}
+	return nil
+}
+
+//LevelDBStorage is a Storage backed by a LevelDB database on disk.
+type LevelDBStorage struct {
+	db *leveldb.DB
+}
+
+//NewLevelDBStorage opens (or creates) a LevelDB database at path.
+func NewLevelDBStorage(path string) (*LevelDBStorage, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBStorage{db: db}, nil
+}
+
+//Get returns the value stored under key, or an error if it is not present.
+func (s *LevelDBStorage) Get(key []byte) ([]byte, error) {
+	return s.db.Get(key, nil)
+}
+
+//Put writes value under key, overwriting any existing entry.
+func (s *LevelDBStorage) Put(key []byte, value []byte) error {
+	return s.db.Put(key, value, nil)
+}
+
+//Delete removes key. It is not an error to delete a key that is not present.
+func (s *LevelDBStorage) Delete(key []byte) error {
+	return s.db.Delete(key, nil)
+}
+
+//Batch applies ops as a single atomic LevelDB write batch.
+func (s *LevelDBStorage) Batch(ops []BatchOp) error {
+	batch := new(leveldb.Batch)
+	for _, op := range ops {
+		if op.Value == nil {
+			batch.Delete(op.Key)
+			continue
+		}
+		batch.Put(op.Key, op.Value)
+	}
+	return s.db.Write(batch, nil)
+}
+
+//Close releases the underlying LevelDB database handle.
+func (s *LevelDBStorage) Close() error {
+	return s.db.Close()
+}
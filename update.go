@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+)
+
+//nodeRecord is the on-disk encoding of a Node written to Storage, keyed by its
+//own hash. It carries just enough information to describe the node's place in
+//the hash graph; the leaf's Content itself is not serialized here since Content
+//only knows how to hash and compare itself, not marshal.
+type nodeRecord struct {
+	Leaf      bool
+	LeftHash  []byte
+	RightHash []byte
+}
+
+//encodeNodeRecord packs n into the wire format written to Storage: a single
+//flag byte followed by the left and right child hashes (zero-length for a leaf).
+func encodeNodeRecord(n *Node) []byte {
+	var left, right []byte
+	if !n.leaf {
+		left = n.Left.Hash
+		right = n.Right.Hash
+	}
+	buf := make([]byte, 1+len(left)+len(right))
+	if n.leaf {
+		buf[0] = 1
+	}
+	copy(buf[1:], left)
+	copy(buf[1+len(left):], right)
+	return buf
+}
+
+//decodeNodeRecord unpacks the wire format written by encodeNodeRecord, reading
+//hashSize bytes per child hash.
+func decodeNodeRecord(data []byte, hashSize int) (nodeRecord, error) {
+	if len(data) < 1 {
+		return nodeRecord{}, errors.New("error: node record data too short")
+	}
+	rec := nodeRecord{Leaf: data[0] == 1}
+	if rec.Leaf {
+		return rec, nil
+	}
+	if len(data) != 1+2*hashSize {
+		return nodeRecord{}, errors.New("error: node record data has unexpected length")
+	}
+	rec.LeftHash = append([]byte{}, data[1:1+hashSize]...)
+	rec.RightHash = append([]byte{}, data[1+hashSize:1+2*hashSize]...)
+	return rec, nil
+}
+
+//persistTree writes n and its entire subtree to the tree's storage backend as a
+//single batch, so a crash partway through never leaves a node's children
+//written without it (or vice versa).
+func (m *MerkleTree) persistTree(n *Node) error {
+	if n == nil || m.storage == nil {
+		return nil
+	}
+	return m.storage.Batch(collectNodeOps(n, nil))
+}
+
+//collectNodeOps appends a BatchOp for n and, recursively, every node in its
+//subtree, to ops.
+func collectNodeOps(n *Node, ops []BatchOp) []BatchOp {
+	ops = append(ops, BatchOp{Key: n.Hash, Value: encodeNodeRecord(n)})
+	if n.leaf {
+		return ops
+	}
+	ops = collectNodeOps(n.Left, ops)
+	return collectNodeOps(n.Right, ops)
+}
+
+//persistPath recomputes the hash of every ancestor of n, up to and including
+//the root, after n's own hash has already been changed, and writes n plus that
+//whole ancestor chain to storage as a single batch. This touches only the
+//O(log n) nodes on n's path instead of rebuilding the tree, and the batch means
+//a crash partway through an update never leaves storage with an ancestor hash
+//that doesn't match what was actually written below it.
+func (m *MerkleTree) persistPath(n *Node) error {
+	ops := []BatchOp{{Key: n.Hash, Value: encodeNodeRecord(n)}}
+
+	current := n.Parent
+	for current != nil {
+		hash, err := current.calculateNodeHash(m.hashStrategy, !m.legacyMode)
+		if err != nil {
+			return err
+		}
+		current.Hash = hash
+		ops = append(ops, BatchOp{Key: current.Hash, Value: encodeNodeRecord(current)})
+		current = current.Parent
+	}
+	m.merkleRoot = m.Root.Hash
+
+	if m.storage == nil {
+		return nil
+	}
+	return m.storage.Batch(ops)
+}
+
+//Update replaces old with new in the tree, recomputing only the nodes on the
+//affected leaf's path to the root rather than rebuilding the whole tree.
+//Returns an error if old is not found in the tree.
+func (m *MerkleTree) Update(old Content, new Content) error {
+	for _, l := range m.Leafs {
+		ok, err := l.C.Equals(old)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		contentHash, err := new.CalculateHash()
+		if err != nil {
+			return err
+		}
+		hash, err := hashLeaf(contentHash, m.hashStrategy, !m.legacyMode)
+		if err != nil {
+			return err
+		}
+		l.C = new
+		l.Hash = hash
+		return m.persistPath(l)
+	}
+	return errors.New("error: content not found in tree")
+}
+
+//Delete removes content from the tree. Rather than reshaping the tree (which
+//would change every other leaf's proof path), the removed leaf is turned into a
+//duplicate of its sibling, mirroring the odd-leaf-count handling already used by
+//buildWithContent. Returns an error if content is not found, or if it is the
+//only content in the tree.
+func (m *MerkleTree) Delete(content Content) error {
+	for i, l := range m.Leafs {
+		ok, err := l.C.Equals(content)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if l.Parent == nil {
+			return errors.New("error: cannot delete the only content in the tree")
+		}
+		var sibling *Node
+		if l.Parent.Left == l {
+			sibling = l.Parent.Right
+		} else {
+			sibling = l.Parent.Left
+		}
+
+		l.C = sibling.C
+		l.Hash = sibling.Hash
+		l.dup = true
+		m.Leafs[i] = l
+		return m.persistPath(l)
+	}
+	return errors.New("error: content not found in tree")
+}
+
+//Add inserts content as new leaf content in the tree. When a trailing duplicate
+//leaf (left over from an odd-sized tree) is available it is replaced in place,
+//an O(log n) update that leaves the tree shape untouched. Otherwise the tree
+//must grow a leaf, which changes every leaf's proof path, so Add falls back to
+//rebuilding the tree from its current content plus the new addition.
+func (m *MerkleTree) Add(content Content) error {
+	contentHash, err := content.CalculateHash()
+	if err != nil {
+		return err
+	}
+	hash, err := hashLeaf(contentHash, m.hashStrategy, !m.legacyMode)
+	if err != nil {
+		return err
+	}
+
+	if n := len(m.Leafs); n > 0 && m.Leafs[n-1].dup {
+		dup := m.Leafs[n-1]
+		dup.C = content
+		dup.Hash = hash
+		dup.dup = false
+		return m.persistPath(dup)
+	}
+
+	cs := make([]Content, 0, len(m.Leafs)+1)
+	for _, l := range m.Leafs {
+		cs = append(cs, l.C)
+	}
+	cs = append(cs, content)
+
+	root, leafs, err := buildWithContent(cs, m.hashStrategy, !m.legacyMode)
+	if err != nil {
+		return err
+	}
+	m.Root = root
+	m.Leafs = leafs
+	m.merkleRoot = root.Hash
+	return m.persistTree(root)
+}
+
+//LoadTree rehydrates a MerkleTree's node graph from storage, starting from a
+//previously retained root hash. The walk reads every node reachable from root
+//and builds the whole pointer graph in memory before returning. This is what makes the old roots kept around after
+//Add/Update/Delete useful for snapshot/rollback: pass any former merkleRoot
+//back in here to get a tree positioned at that snapshot again.
+//
+//Storage only ever learns a node's hash and its children's hashes (see
+//encodeNodeRecord), never the original Content a leaf was built from, so
+//leaves on a loaded tree come back with a nil C. VerifyContent and
+//GenerateProof skip nil-C leaves since they need real Content to compare
+//against, so neither can find a match on a fully loaded tree; use VerifyTree
+//instead, which LoadTree's leaves support by trusting the persisted leaf hash
+//instead of recomputing it from Content.
+func LoadTree(storage Storage, root []byte, hashStrategy HashStrategy, legacyMode bool) (*MerkleTree, error) {
+	if storage == nil {
+		return nil, errors.New("error: storage is required to load a tree")
+	}
+
+	rootNode, err := loadNode(storage, root, hashStrategy().Size())
+	if err != nil {
+		return nil, err
+	}
+
+	return &MerkleTree{
+		Root:         rootNode,
+		merkleRoot:   append([]byte{}, root...),
+		Leafs:        collectLeafs(rootNode),
+		storage:      storage,
+		hashStrategy: hashStrategy,
+		legacyMode:   legacyMode,
+	}, nil
+}
+
+//loadNode reads the node record for hash from storage and, if it is not a
+//leaf, recurses into its children, reconstructing the Parent/Left/Right
+//pointer graph as it goes.
+func loadNode(storage Storage, hash []byte, hashSize int) (*Node, error) {
+	data, err := storage.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := decodeNodeRecord(data, hashSize)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{Hash: append([]byte{}, hash...), leaf: rec.Leaf}
+	if rec.Leaf {
+		return n, nil
+	}
+
+	n.Left, err = loadNode(storage, rec.LeftHash, hashSize)
+	if err != nil {
+		return nil, err
+	}
+	n.Right, err = loadNode(storage, rec.RightHash, hashSize)
+	if err != nil {
+		return nil, err
+	}
+	n.Left.Parent = n
+	n.Right.Parent = n
+	return n, nil
+}
+
+//collectLeafs walks n's subtree left to right and returns its leaf nodes. A
+//node that buildIntermediate paired with itself is loaded as two separate
+//children, so its leaves appear twice and the result is then longer than the
+//Leafs buildWithContent produced.
+func collectLeafs(n *Node) []*Node {
+	if n.leaf {
+		return []*Node{n}
+	}
+	return append(collectLeafs(n.Left), collectLeafs(n.Right)...)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+//TestAddUpdateDeleteWithStorage exercises Add/Update/Delete against a tree
+//backed by MemoryStorage, checking VerifyTree still passes after each
+//mutation and that the resulting root can be rehydrated with LoadTree.
+func TestAddUpdateDeleteWithStorage(t *testing.T) {
+	storage := NewMemoryStorage()
+	cs := []Content{TestContent{x: "a"}, TestContent{x: "b"}, TestContent{x: "c"}}
+	tree, err := SetupWithOptions(cs, WithStorage(storage))
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+
+	if err := tree.Add(TestContent{x: "d"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if ok, err := tree.VerifyTree(); err != nil || !ok {
+		t.Fatalf("VerifyTree after Add = %v, %v", ok, err)
+	}
+
+	if err := tree.Update(TestContent{x: "b"}, TestContent{x: "b2"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if ok, err := tree.VerifyTree(); err != nil || !ok {
+		t.Fatalf("VerifyTree after Update = %v, %v", ok, err)
+	}
+	if ok, _ := tree.VerifyContent(TestContent{x: "b2"}); !ok {
+		t.Fatal("VerifyContent(b2) = false after Update")
+	}
+
+	if err := tree.Delete(TestContent{x: "c"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ok, err := tree.VerifyTree(); err != nil || !ok {
+		t.Fatalf("VerifyTree after Delete = %v, %v", ok, err)
+	}
+	if ok, _ := tree.VerifyContent(TestContent{x: "c"}); ok {
+		t.Fatal("VerifyContent(c) = true after Delete")
+	}
+
+	loaded, err := LoadTree(storage, tree.merkleRoot, tree.hashStrategy, tree.legacyMode)
+	if err != nil {
+		t.Fatalf("LoadTree failed: %v", err)
+	}
+	if !bytes.Equal(loaded.merkleRoot, tree.merkleRoot) {
+		t.Fatalf("loaded root %x != original root %x", loaded.merkleRoot, tree.merkleRoot)
+	}
+	if ok, err := loaded.VerifyTree(); err != nil || !ok {
+		t.Fatalf("VerifyTree on loaded tree = %v, %v", ok, err)
+	}
+}
+
+//TestLoadTreeLeavesHaveNoContent checks the documented LoadTree limitation:
+//rehydrated leaves carry a nil C, so VerifyContent/GenerateProof report "not
+//found" rather than matching or panicking.
+func TestLoadTreeLeavesHaveNoContent(t *testing.T) {
+	storage := NewMemoryStorage()
+	cs := []Content{TestContent{x: "x"}, TestContent{x: "y"}}
+	tree, err := SetupWithOptions(cs, WithStorage(storage))
+	if err != nil {
+		t.Fatalf("SetupWithOptions failed: %v", err)
+	}
+
+	loaded, err := LoadTree(storage, tree.merkleRoot, tree.hashStrategy, tree.legacyMode)
+	if err != nil {
+		t.Fatalf("LoadTree failed: %v", err)
+	}
+
+	for _, l := range loaded.Leafs {
+		if l.C != nil {
+			t.Fatal("loaded leaf has non-nil Content")
+		}
+	}
+	if ok, err := loaded.VerifyContent(TestContent{x: "x"}); err != nil || ok {
+		t.Fatalf("VerifyContent on loaded tree = %v, %v, want false, nil", ok, err)
+	}
+	if _, err := loaded.GenerateProof(TestContent{x: "x"}); err == nil {
+		t.Fatal("GenerateProof on loaded tree = nil error, want not-found error")
+	}
+}
+
+//TestMemoryStorageBatch checks that Batch applies both puts and deletes
+//(signalled by a nil Value) in one call.
+func TestMemoryStorageBatch(t *testing.T) {
+	storage := NewMemoryStorage()
+	if err := storage.Put([]byte("keep"), []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := storage.Put([]byte("drop"), []byte("v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	err := storage.Batch([]BatchOp{
+		{Key: []byte("keep"), Value: []byte("v1-updated")},
+		{Key: []byte("drop"), Value: nil},
+		{Key: []byte("new"), Value: []byte("v3")},
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+
+	v, err := storage.Get([]byte("keep"))
+	if err != nil || string(v) != "v1-updated" {
+		t.Fatalf("Get(keep) = %q, %v, want v1-updated, nil", v, err)
+	}
+	if _, err := storage.Get([]byte("drop")); err == nil {
+		t.Fatal("Get(drop) succeeded after Batch delete, want error")
+	}
+	v, err = storage.Get([]byte("new"))
+	if err != nil || string(v) != "v3" {
+		t.Fatalf("Get(new) = %q, %v, want v3, nil", v, err)
+	}
+}